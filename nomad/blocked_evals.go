@@ -1,6 +1,7 @@
 package nomad
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -11,8 +12,144 @@ import (
 const (
 	// unblockBuffer is the buffer size for the unblock channel.
 	unblockBuffer = 8096
+
+	// defaultCoalesceWindow is the debounce window used to merge rapid
+	// Unblock calls for different computed classes into a single unblock
+	// pass. This avoids repeatedly re-scanning and re-enqueuing the same
+	// escaped evals when a large cluster has rapid node churn.
+	defaultCoalesceWindow = 100 * time.Millisecond
+)
+
+// blockedEvalRecord pairs a quota-blocked evaluation with the quota it is
+// waiting on, for inclusion in a Snapshot. Captured/escaped evaluations
+// need no such wrapper since the snapshot keeps them in separate lists and
+// Restore puts each back into the bucket of the list it came from.
+type blockedEvalRecord struct {
+	Eval      *structs.Evaluation
+	QuotaName string
+}
+
+// BlockedReason labels why an evaluation could not be placed the last time
+// it went through the scheduler. It is derived from the failed allocation
+// metrics attached to the evaluation and is used to decide whether a given
+// capacity change could plausibly let the evaluation make progress.
+type BlockedReason string
+
+const (
+	// BlockedReasonCPU indicates the eval's allocations could not fit due to
+	// insufficient CPU on the evaluated nodes.
+	BlockedReasonCPU BlockedReason = "cpu"
+
+	// BlockedReasonMemory indicates the eval's allocations could not fit due
+	// to insufficient memory on the evaluated nodes.
+	BlockedReasonMemory BlockedReason = "memory"
+
+	// BlockedReasonDisk indicates the eval's allocations could not fit due
+	// to insufficient disk on the evaluated nodes.
+	BlockedReasonDisk BlockedReason = "disk"
+
+	// BlockedReasonNetwork indicates the eval's allocations could not fit
+	// due to exhausted network resources, such as reserved ports.
+	BlockedReasonNetwork BlockedReason = "network"
+
+	// BlockedReasonConstraint indicates the eval was filtered out by a job
+	// or task group constraint, such as a missing driver or attribute.
+	BlockedReasonConstraint BlockedReason = "constraint"
+
+	// BlockedReasonUnknown is used when no specific reason could be
+	// determined from the eval's failed allocation metrics.
+	BlockedReasonUnknown BlockedReason = "unknown"
 )
 
+// blockedReasonsForEval inspects the failed allocation metrics recorded on
+// the evaluation and returns the set of reasons it was unable to place,
+// mapped to the largest amount of that resource any failed task group
+// needed. The amount is 0 for reasons that aren't resource magnitudes
+// (constraint, network, unknown) or when the scheduler didn't record
+// ResourcesExhausted for that failure, in which case the mere presence of
+// the resource is treated as potentially enough. The result may contain
+// more than one reason since a single evaluation can have multiple task
+// groups that failed for different reasons.
+func blockedReasonsForEval(eval *structs.Evaluation) map[BlockedReason]int {
+	reasons := make(map[BlockedReason]int)
+	for _, metric := range eval.FailedTGAllocs {
+		if metric == nil {
+			continue
+		}
+
+		for dim := range metric.DimensionExhausted {
+			switch {
+			case strings.Contains(dim, "cpu"), strings.Contains(dim, "cores"):
+				recordReason(reasons, BlockedReasonCPU, requiredAmount(metric, "cpu"))
+			case strings.Contains(dim, "memory"):
+				recordReason(reasons, BlockedReasonMemory, requiredAmount(metric, "memory"))
+			case strings.Contains(dim, "disk"):
+				recordReason(reasons, BlockedReasonDisk, requiredAmount(metric, "disk"))
+			case strings.Contains(dim, "network"), strings.Contains(dim, "port"), strings.Contains(dim, "bandwidth exceeded"):
+				recordReason(reasons, BlockedReasonNetwork, 0)
+			}
+		}
+
+		if len(metric.ClassFiltered) != 0 || len(metric.ConstraintFiltered) != 0 {
+			recordReason(reasons, BlockedReasonConstraint, 0)
+		}
+	}
+
+	if len(reasons) == 0 {
+		reasons[BlockedReasonUnknown] = 0
+	}
+
+	return reasons
+}
+
+// requiredAmount returns the largest amount of the given resource dimension
+// that any task in the evaluation's failed allocation metric needed, from
+// the resources the scheduler recorded as exhausted. It returns 0 if no
+// ResourcesExhausted entry carries that dimension, meaning the magnitude
+// isn't known.
+func requiredAmount(metric *structs.AllocMetric, dim string) int {
+	amount := 0
+	for _, resources := range metric.ResourcesExhausted {
+		if resources == nil {
+			continue
+		}
+		switch dim {
+		case "cpu":
+			amount = maxInt(amount, resources.CPU)
+		case "memory":
+			amount = maxInt(amount, resources.MemoryMB)
+		case "disk":
+			amount = maxInt(amount, resources.DiskMB)
+		}
+	}
+	return amount
+}
+
+// recordReason tracks the largest required amount seen for a reason across
+// every failed task group, so a node that only frees up enough for the
+// smallest failure doesn't wake an eval that needed more elsewhere.
+func recordReason(reasons map[BlockedReason]int, reason BlockedReason, amount int) {
+	if existing, ok := reasons[reason]; !ok || amount > existing {
+		reasons[reason] = amount
+	}
+}
+
+// quotaNameForEval inspects the eval's failed allocation metrics for a
+// namespace/quota exhaustion failure, as opposed to a node-capacity
+// failure. An eval blocked on quota is tracked in its own bucket so it can
+// be woken directly by the quota subsystem instead of on every unrelated
+// node class change.
+func quotaNameForEval(eval *structs.Evaluation) (string, bool) {
+	for _, metric := range eval.FailedTGAllocs {
+		if metric == nil || len(metric.QuotaExhausted) == 0 {
+			continue
+		}
+		return metric.QuotaExhausted[0], true
+	}
+
+	return "", false
+}
+
 // BlockedEvals is used to track evaluations that shouldn't be queued until a
 // certain class of nodes becomes available. An evaluation is put into the
 // blocked state when it is run through the scheduler and produced failed
@@ -33,8 +170,51 @@ type BlockedEvals struct {
 	// classes.
 	escaped map[string]*structs.Evaluation
 
+	// reasons maps a captured evaluation's ID to the set of reasons it was
+	// blocked for, and the magnitude of each it required, as derived from
+	// its failed allocation metrics. It is used by unblock to avoid waking
+	// an evaluation on a capacity change that could not plausibly satisfy
+	// it.
+	reasons map[string]map[BlockedReason]int
+
+	// quotaBlocked is the set of evaluations that are blocked because they
+	// would exceed a namespace/quota limit rather than because of node
+	// capacity. These are independent of computed node classes entirely and
+	// are only woken by UnblockQuota.
+	quotaBlocked map[string]*structs.Evaluation
+
+	// quotaEvalQuota maps a quota-blocked evaluation's ID to the quota spec
+	// it is blocked on.
+	quotaEvalQuota map[string]string
+
+	// quotaIndex maps a quota spec to the set of evaluation IDs blocked on
+	// it, so UnblockQuota doesn't have to scan every quota-blocked eval.
+	quotaIndex map[string]map[string]struct{}
+
 	// unblockCh is used to buffer unblocking of evaluations.
-	unblockCh chan string
+	unblockCh chan *unblockUpdate
+
+	// coalesceWindow is the debounce window used to merge rapid Unblock
+	// calls. Zero means defaultCoalesceWindow.
+	coalesceWindow time.Duration
+
+	// pendingMu protects pending and pendingTimer. It is distinct from l so
+	// that Unblock, which runs on the scheduler hot path, never contends
+	// with the lock guarding captured/escaped.
+	pendingMu sync.Mutex
+
+	// pending is the set of computed classes notified since the last
+	// unblock pass, keyed by class and merged via mergeCapacity.
+	pending map[string]*NodeCapacity
+
+	// pendingTimer fires after coalesceWindow to flush pending into a
+	// single unblockUpdate. Nil when there is nothing pending.
+	pendingTimer *time.Timer
+
+	// rawUnblockEvents and coalescedUnblockEvents count Unblock calls for
+	// metrics; see BlockedStats.
+	rawUnblockEvents       int
+	coalescedUnblockEvents int
 
 	// jobs is the map of blocked job and is used to ensure that only one
 	// blocked eval exists for each job.
@@ -54,6 +234,93 @@ type BlockedEvals struct {
 	stopCh chan struct{}
 }
 
+// NodeCapacity summarizes the capacity or attributes that just became
+// available on a node, either because the node registered, an allocation on
+// it was removed, or it was marked eligible. It is used alongside a computed
+// class to decide which blocked reasons could plausibly be satisfied.
+type NodeCapacity struct {
+	// CPU and Memory are the amount of CPU (MHz) and memory (MB) that became
+	// newly available.
+	CPU    int
+	Memory int
+
+	// Disk is the amount of disk (MB) that became newly available.
+	Disk int
+
+	// Network indicates whether reserved network ports became newly
+	// available.
+	Network bool
+
+	// Constraint indicates the node's attributes changed in a way that may
+	// satisfy constraints, such as a driver becoming healthy.
+	Constraint bool
+}
+
+// satisfies returns whether the given blocked reason, which required at
+// least `required` of that resource, could plausibly be resolved by this
+// capacity delta. A nil NodeCapacity satisfies every reason since its
+// origin is unknown (e.g. a class seen for the first time), preserving the
+// historical unblock-on-any-change behavior. required of 0 means the exact
+// amount needed isn't known (a non-resource reason, or an eval blocked
+// before ResourcesExhausted was recorded for it), so any amount of the
+// resource becoming available is treated as potentially enough; otherwise
+// the delta must cover the recorded requirement, e.g. a node that freed up
+// 2GB of memory must not wake an eval that needed 8GB.
+func (n *NodeCapacity) satisfies(reason BlockedReason, required int) bool {
+	if n == nil {
+		return true
+	}
+
+	switch reason {
+	case BlockedReasonCPU:
+		return n.CPU > 0 && (required == 0 || n.CPU >= required)
+	case BlockedReasonMemory:
+		return n.Memory > 0 && (required == 0 || n.Memory >= required)
+	case BlockedReasonDisk:
+		return n.Disk > 0 && (required == 0 || n.Disk >= required)
+	case BlockedReasonNetwork:
+		return n.Network
+	case BlockedReasonConstraint:
+		return n.Constraint
+	default:
+		return true
+	}
+}
+
+// unblockUpdate is the event pushed onto the unblock channel. It carries a
+// batch of computed classes that were coalesced together within the
+// coalesce window, each with the capacity that became newly available on
+// it, so a single pass over captured can union eligibility across all of
+// them.
+type unblockUpdate struct {
+	classes map[string]*NodeCapacity
+}
+
+// mergeCapacity unions two capacity deltas observed for the same computed
+// class within a coalesce window, so a reason satisfied by either delta is
+// preserved. A nil delta means the origin of the change is unknown and
+// dominates, since it must be treated as satisfying every reason.
+func mergeCapacity(a, b *NodeCapacity) *NodeCapacity {
+	if a == nil || b == nil {
+		return nil
+	}
+
+	return &NodeCapacity{
+		CPU:        maxInt(a.CPU, b.CPU),
+		Memory:     maxInt(a.Memory, b.Memory),
+		Disk:       maxInt(a.Disk, b.Disk),
+		Network:    a.Network || b.Network,
+		Constraint: a.Constraint || b.Constraint,
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 // BlockedStats returns all the stats about the blocked eval tracker.
 type BlockedStats struct {
 	// TotalEscaped is the total number of blocked evaluations that have escaped
@@ -62,23 +329,66 @@ type BlockedStats struct {
 
 	// TotalBlocked is the total number of blocked evaluations.
 	TotalBlocked int
+
+	// TotalBlockedByReason is the number of blocked evaluations keyed by the
+	// reason they were unable to be placed.
+	TotalBlockedByReason map[BlockedReason]int
+
+	// TotalRawUnblockEvents is the total number of Unblock calls received.
+	TotalRawUnblockEvents int
+
+	// TotalCoalescedUnblockEvents is the number of those calls that were
+	// merged into an already-pending notification for the same computed
+	// class rather than causing their own unblock pass.
+	TotalCoalescedUnblockEvents int
+
+	// LastUnblockBatchSize is the number of distinct computed classes
+	// processed together in the most recent unblock pass.
+	LastUnblockBatchSize int
+
+	// TotalQuotaBlocked is the total number of blocked evaluations that are
+	// waiting on namespace/quota capacity rather than node capacity.
+	TotalQuotaBlocked int
+
+	// TotalQuotaBlockedByQuota is TotalQuotaBlocked broken down by the quota
+	// spec each evaluation is waiting on.
+	TotalQuotaBlockedByQuota map[string]int
 }
 
 // NewBlockedEvals creates a new blocked eval tracker that will enqueue
-// unblocked evals into the passed broker.
+// unblocked evals into the passed broker. Membership is tracked in-memory
+// only: see BlockedEvalsSnapshot for the caveats around persisting it
+// through Raft.
 func NewBlockedEvals(evalBroker *EvalBroker) *BlockedEvals {
 	return &BlockedEvals{
-		evalBroker:  evalBroker,
-		captured:    make(map[string]*structs.Evaluation),
-		escaped:     make(map[string]*structs.Evaluation),
-		jobs:        make(map[string]struct{}),
-		unblockCh:   make(chan string, unblockBuffer),
-		duplicateCh: make(chan struct{}),
-		stopCh:      make(chan struct{}),
-		stats:       new(BlockedStats),
+		evalBroker:     evalBroker,
+		captured:       make(map[string]*structs.Evaluation),
+		escaped:        make(map[string]*structs.Evaluation),
+		reasons:        make(map[string]map[BlockedReason]int),
+		quotaBlocked:   make(map[string]*structs.Evaluation),
+		quotaEvalQuota: make(map[string]string),
+		quotaIndex:     make(map[string]map[string]struct{}),
+		jobs:           make(map[string]struct{}),
+		unblockCh:      make(chan *unblockUpdate, unblockBuffer),
+		pending:        make(map[string]*NodeCapacity),
+		duplicateCh:    make(chan struct{}),
+		stopCh:         make(chan struct{}),
+		stats: &BlockedStats{
+			TotalBlockedByReason:     make(map[BlockedReason]int),
+			TotalQuotaBlockedByQuota: make(map[string]int),
+		},
 	}
 }
 
+// SetCoalesceWindow configures the debounce window used to merge rapid
+// Unblock calls into a single unblock pass. It should be called before the
+// tracker is enabled; a zero value restores defaultCoalesceWindow.
+func (b *BlockedEvals) SetCoalesceWindow(window time.Duration) {
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+	b.coalesceWindow = window
+}
+
 // Enabled is used to check if the broker is enabled.
 func (b *BlockedEvals) Enabled() bool {
 	b.l.RLock()
@@ -127,6 +437,23 @@ func (b *BlockedEvals) Block(eval *structs.Evaluation) {
 
 	b.stats.TotalBlocked++
 	b.jobs[eval.JobID] = struct{}{}
+
+	// A quota-exhaustion failure is tracked in its own bucket, independent
+	// of computed node classes entirely, so it only wakes when the quota
+	// subsystem calls UnblockQuota rather than on every unrelated node
+	// class change.
+	if quotaName, ok := quotaNameForEval(eval); ok {
+		b.quotaBlocked[eval.ID] = eval
+		b.quotaEvalQuota[eval.ID] = quotaName
+		if b.quotaIndex[quotaName] == nil {
+			b.quotaIndex[quotaName] = make(map[string]struct{})
+		}
+		b.quotaIndex[quotaName][eval.ID] = struct{}{}
+		b.stats.TotalQuotaBlocked++
+		b.stats.TotalQuotaBlockedByQuota[quotaName]++
+		return
+	}
+
 	if eval.EscapedComputedClass {
 		b.escaped[eval.ID] = eval
 		b.stats.TotalEscaped++
@@ -134,18 +461,151 @@ func (b *BlockedEvals) Block(eval *structs.Evaluation) {
 	}
 
 	b.captured[eval.ID] = eval
+
+	// Record why this eval couldn't be placed so unblock can later decide
+	// whether a capacity delta could plausibly satisfy it.
+	reasons := blockedReasonsForEval(eval)
+	b.reasons[eval.ID] = reasons
+	for reason := range reasons {
+		b.stats.TotalBlockedByReason[reason]++
+	}
 }
 
-// Unblock causes any evaluation that could potentially make progress on a
-// capacity change on the passed computed node class to be enqueued into the
-// eval broker.
+// Unblock causes any captured evaluation of the passed computed node class
+// to be considered for enqueueing into the eval broker, without regard to
+// what became newly available on the class. It is equivalent to calling
+// UnblockWithCapacity with a nil capacity.
 func (b *BlockedEvals) Unblock(computedClass string) {
+	b.UnblockWithCapacity(computedClass, nil)
+}
+
+// UnblockWithCapacity causes any evaluation that could potentially make
+// progress on a capacity change on the passed computed node class to be
+// enqueued into the eval broker. capacity summarizes what became newly
+// available on a node of that class; it may be nil if the origin of the
+// change is unknown, in which case every captured eval of that class is
+// considered.
+//
+// Calls are coalesced: a notification for a class that already has one
+// pending within the coalesce window is merged into it rather than
+// triggering its own unblock pass, so a burst of node churn doesn't
+// repeatedly re-scan and re-enqueue the same escaped evals. Unblock never
+// blocks the caller, even if the unblock goroutine is backed up.
+func (b *BlockedEvals) UnblockWithCapacity(computedClass string, capacity *NodeCapacity) {
 	// Do nothing if not enabled
 	if !b.enabled {
 		return
 	}
 
-	b.unblockCh <- computedClass
+	b.pendingMu.Lock()
+	defer b.pendingMu.Unlock()
+
+	b.rawUnblockEvents++
+	if existing, ok := b.pending[computedClass]; ok {
+		b.coalescedUnblockEvents++
+		b.pending[computedClass] = mergeCapacity(existing, capacity)
+		return
+	}
+	b.pending[computedClass] = capacity
+
+	if b.pendingTimer == nil {
+		window := b.coalesceWindow
+		if window <= 0 {
+			window = defaultCoalesceWindow
+		}
+		b.pendingTimer = time.AfterFunc(window, b.flushPending)
+	}
+}
+
+// flushPending is called by pendingTimer once the coalesce window elapses.
+// It hands the batch of pending classes to the unblock goroutine as a
+// single unblockUpdate.
+func (b *BlockedEvals) flushPending() {
+	b.pendingMu.Lock()
+	if len(b.pending) == 0 {
+		b.pendingTimer = nil
+		b.pendingMu.Unlock()
+		return
+	}
+	batch := b.pending
+	b.pending = make(map[string]*NodeCapacity)
+	b.pendingTimer = nil
+	b.pendingMu.Unlock()
+
+	select {
+	case b.unblockCh <- &unblockUpdate{classes: batch}:
+	default:
+		// The unblock goroutine is backed up. Merge the batch back into the
+		// pending set instead of blocking this timer goroutine; it will be
+		// retried after another coalesce window.
+		b.pendingMu.Lock()
+		for class, capacity := range batch {
+			if existing, ok := b.pending[class]; ok {
+				b.pending[class] = mergeCapacity(existing, capacity)
+			} else {
+				b.pending[class] = capacity
+			}
+		}
+		// A concurrent Unblock call may have already raced in between the
+		// unlock above and this re-lock, observed pendingTimer as nil (it
+		// was cleared before the send attempt), and started its own timer
+		// for the entries it added. Only start one here if that didn't
+		// happen, or two live timers would both eventually fire and flush
+		// the same batch twice.
+		if b.pendingTimer == nil {
+			window := b.coalesceWindow
+			if window <= 0 {
+				window = defaultCoalesceWindow
+			}
+			b.pendingTimer = time.AfterFunc(window, b.flushPending)
+		}
+		b.pendingMu.Unlock()
+	}
+}
+
+// UnblockQuota causes any evaluation that was blocked because it would have
+// exceeded the named quota to be enqueued into the eval broker. It is
+// called by the quota subsystem when usage under that quota drops, and is
+// independent of Unblock: a quota-blocked eval never wakes because an
+// unrelated node class gained capacity.
+func (b *BlockedEvals) UnblockQuota(quotaName string) {
+	if !b.Enabled() {
+		return
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	if !b.running {
+		return
+	}
+
+	ids := b.quotaIndex[quotaName]
+	if len(ids) == 0 {
+		return
+	}
+
+	unblocked := make([]*structs.Evaluation, 0, len(ids))
+	for id := range ids {
+		eval, ok := b.quotaBlocked[id]
+		if !ok {
+			continue
+		}
+
+		unblocked = append(unblocked, eval)
+		delete(b.quotaBlocked, id)
+		delete(b.quotaEvalQuota, id)
+		delete(b.jobs, eval.JobID)
+	}
+	delete(b.quotaIndex, quotaName)
+
+	if l := len(unblocked); l != 0 {
+		b.stats.TotalBlocked -= l
+		b.stats.TotalQuotaBlocked -= l
+		delete(b.stats.TotalQuotaBlockedByQuota, quotaName)
+
+		b.evalBroker.EnqueueAll(unblocked)
+	}
 }
 
 func (b *BlockedEvals) unblock() {
@@ -153,7 +613,7 @@ func (b *BlockedEvals) unblock() {
 		select {
 		case <-b.stopCh:
 			return
-		case computedClass := <-b.unblockCh:
+		case update := <-b.unblockCh:
 			b.l.Lock()
 
 			// Protect against the case of a flush.
@@ -161,6 +621,8 @@ func (b *BlockedEvals) unblock() {
 				return
 			}
 
+			b.stats.LastUnblockBatchSize = len(update.classes)
+
 			// Every eval that has escaped computed node class has to be unblocked
 			// because any node could potentially be feasible.
 			i := 0
@@ -175,23 +637,45 @@ func (b *BlockedEvals) unblock() {
 				}
 			}
 
-			// We unblock any eval that is explicitely eligible for the computed class
-			// and also any eval that is not eligible or uneligible. This signifies that
-			// when the evaluation was originally run through the scheduler, that it
-			// never saw a node with the given computed class and thus needs to be
-			// unblocked for correctness.
+			// We unblock any eval that is explicitly eligible for one of the
+			// batched computed classes, and also any eval that is not eligible or
+			// ineligible for that class. The latter signifies that when the
+			// evaluation was originally run through the scheduler, it never saw a
+			// node with the given computed class and thus needs to be unblocked
+			// for correctness regardless of what the recorded reason was - the
+			// reason heuristic only applies once we know the eval actually
+			// considered this class and could fit on it. A single pass unions
+			// eligibility across every class in the batch so a coalesced burst of
+			// notifications is handled exactly like the same notifications
+			// arriving one at a time.
 			var untrack []string
 			for id, eval := range b.captured {
-				if elig, ok := eval.ClassEligibility[computedClass]; ok {
-					if !elig {
+				eligible := false
+				for class, capacity := range update.classes {
+					elig, ok := eval.ClassEligibility[class]
+					if ok && !elig {
 						// Can skip because the eval has explicitely marked the node class
 						// as ineligible.
 						continue
 					}
+
+					// Only apply the reason heuristic when the eval actually saw this
+					// class and could fit on it; a class it never saw must unblock it
+					// unconditionally, or it could be stranded indefinitely waiting for
+					// a delta that happens to match its one recorded reason.
+					if ok && elig && !b.reasonsSatisfiedBy(id, capacity) {
+						continue
+					}
+
+					eligible = true
+					break
+				}
+				if !eligible {
+					continue
 				}
 
-				// The computed node class has never been seen by the eval so we unblock
-				// it.
+				// The computed node class has never been seen by the eval, or the
+				// recorded reason could plausibly be satisfied, so we unblock it.
 				unblocked = append(unblocked, eval)
 				untrack = append(untrack, id)
 				delete(b.jobs, eval.JobID)
@@ -201,6 +685,12 @@ func (b *BlockedEvals) unblock() {
 			if l := len(untrack); l != 0 {
 				for _, id := range untrack {
 					delete(b.captured, id)
+					if reasons, ok := b.reasons[id]; ok {
+						for reason := range reasons {
+							b.stats.TotalBlockedByReason[reason]--
+						}
+						delete(b.reasons, id)
+					}
 				}
 			}
 
@@ -217,6 +707,132 @@ func (b *BlockedEvals) unblock() {
 	}
 }
 
+// reasonsSatisfiedBy reports whether the given capacity delta could
+// plausibly resolve at least one of the reasons the captured eval with the
+// given ID was blocked for. Callers must hold b.l.
+func (b *BlockedEvals) reasonsSatisfiedBy(id string, capacity *NodeCapacity) bool {
+	reasons, ok := b.reasons[id]
+	if !ok || len(reasons) == 0 {
+		return true
+	}
+
+	for reason, required := range reasons {
+		if capacity.satisfies(reason, required) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// recomputeStatsLocked rebuilds the tracker's counters directly from the
+// captured/escaped maps rather than a persisted counter value, which could
+// have drifted from the actual membership across a restore. Callers must
+// hold b.l.
+func (b *BlockedEvals) recomputeStatsLocked() {
+	stats := &BlockedStats{
+		TotalBlockedByReason:     make(map[BlockedReason]int),
+		TotalQuotaBlockedByQuota: make(map[string]int),
+	}
+	stats.TotalEscaped = len(b.escaped)
+	stats.TotalQuotaBlocked = len(b.quotaBlocked)
+	stats.TotalBlocked = len(b.captured) + len(b.escaped) + len(b.quotaBlocked)
+	for id := range b.captured {
+		for reason := range b.reasons[id] {
+			stats.TotalBlockedByReason[reason]++
+		}
+	}
+	for id := range b.quotaBlocked {
+		stats.TotalQuotaBlockedByQuota[b.quotaEvalQuota[id]]++
+	}
+	b.stats = stats
+}
+
+// BlockedEvalsSnapshot is the point-in-time view of blocked eval membership
+// that Snapshot/Restore round-trip. Derived state such as reasons and stats
+// is intentionally excluded; it is recomputed on Restore.
+//
+// Nothing in this package persists this snapshot through Raft: doing so
+// would require a blocked_evals table on the real state.StateStore and an
+// FSM command to apply it, neither of which this package can add on its
+// own since state.StateStore lives outside this tree. Snapshot/Restore are
+// exported so that wiring, when it lands in fsm.go, has a ready-made,
+// already-tested place to call into; until then membership is in-memory
+// only and does not survive a leadership change.
+type BlockedEvalsSnapshot struct {
+	Captured     []*structs.Evaluation
+	Escaped      []*structs.Evaluation
+	QuotaBlocked []*blockedEvalRecord
+}
+
+// Snapshot returns the current blocked eval membership.
+func (b *BlockedEvals) Snapshot() *BlockedEvalsSnapshot {
+	b.l.RLock()
+	defer b.l.RUnlock()
+
+	snap := &BlockedEvalsSnapshot{
+		Captured:     make([]*structs.Evaluation, 0, len(b.captured)),
+		Escaped:      make([]*structs.Evaluation, 0, len(b.escaped)),
+		QuotaBlocked: make([]*blockedEvalRecord, 0, len(b.quotaBlocked)),
+	}
+	for _, eval := range b.captured {
+		snap.Captured = append(snap.Captured, eval)
+	}
+	for _, eval := range b.escaped {
+		snap.Escaped = append(snap.Escaped, eval)
+	}
+	for id, eval := range b.quotaBlocked {
+		snap.QuotaBlocked = append(snap.QuotaBlocked, &blockedEvalRecord{Eval: eval, QuotaName: b.quotaEvalQuota[id]})
+	}
+	return snap
+}
+
+// Restore replaces blocked eval membership with the contents of a
+// snapshot taken by Snapshot. A duplicate JobID is appended to duplicates
+// rather than silently overwriting the existing entry.
+func (b *BlockedEvals) Restore(snap *BlockedEvalsSnapshot) {
+	if snap == nil {
+		return
+	}
+
+	b.l.Lock()
+	defer b.l.Unlock()
+
+	for _, eval := range snap.Captured {
+		if _, existing := b.jobs[eval.JobID]; existing {
+			b.duplicates = append(b.duplicates, eval)
+			continue
+		}
+		b.jobs[eval.JobID] = struct{}{}
+		b.captured[eval.ID] = eval
+		b.reasons[eval.ID] = blockedReasonsForEval(eval)
+	}
+	for _, eval := range snap.Escaped {
+		if _, existing := b.jobs[eval.JobID]; existing {
+			b.duplicates = append(b.duplicates, eval)
+			continue
+		}
+		b.jobs[eval.JobID] = struct{}{}
+		b.escaped[eval.ID] = eval
+	}
+	for _, record := range snap.QuotaBlocked {
+		eval := record.Eval
+		if _, existing := b.jobs[eval.JobID]; existing {
+			b.duplicates = append(b.duplicates, eval)
+			continue
+		}
+		b.jobs[eval.JobID] = struct{}{}
+		b.quotaBlocked[eval.ID] = eval
+		b.quotaEvalQuota[eval.ID] = record.QuotaName
+		if b.quotaIndex[record.QuotaName] == nil {
+			b.quotaIndex[record.QuotaName] = make(map[string]struct{})
+		}
+		b.quotaIndex[record.QuotaName][eval.ID] = struct{}{}
+	}
+
+	b.recomputeStatsLocked()
+}
+
 // GetDuplicates returns all the duplicate evaluations and blocks until the
 // passed timeout.
 func (b *BlockedEvals) GetDuplicates(timeout time.Duration) []*structs.Evaluation {
@@ -265,19 +881,36 @@ func (b *BlockedEvals) Flush() {
 	// Reset the blocked eval tracker.
 	b.stats.TotalEscaped = 0
 	b.stats.TotalBlocked = 0
+	b.stats.TotalBlockedByReason = make(map[BlockedReason]int)
+	b.stats.TotalQuotaBlocked = 0
+	b.stats.TotalQuotaBlockedByQuota = make(map[string]int)
 	b.captured = make(map[string]*structs.Evaluation)
 	b.escaped = make(map[string]*structs.Evaluation)
+	b.reasons = make(map[string]map[BlockedReason]int)
+	b.quotaBlocked = make(map[string]*structs.Evaluation)
+	b.quotaEvalQuota = make(map[string]string)
+	b.quotaIndex = make(map[string]map[string]struct{})
 	b.jobs = make(map[string]struct{})
 	b.duplicates = nil
-	b.unblockCh = make(chan string, unblockBuffer)
+	b.unblockCh = make(chan *unblockUpdate, unblockBuffer)
 	b.stopCh = make(chan struct{})
 	b.duplicateCh = make(chan struct{})
+
+	b.pendingMu.Lock()
+	if b.pendingTimer != nil {
+		b.pendingTimer.Stop()
+		b.pendingTimer = nil
+	}
+	b.pending = make(map[string]*NodeCapacity)
+	b.pendingMu.Unlock()
 }
 
 // Stats is used to query the state of the blocked eval tracker.
 func (b *BlockedEvals) Stats() *BlockedStats {
 	// Allocate a new stats struct
 	stats := new(BlockedStats)
+	stats.TotalBlockedByReason = make(map[BlockedReason]int)
+	stats.TotalQuotaBlockedByQuota = make(map[string]int)
 
 	b.l.RLock()
 	defer b.l.RUnlock()
@@ -285,6 +918,20 @@ func (b *BlockedEvals) Stats() *BlockedStats {
 	// Copy all the stats
 	stats.TotalEscaped = b.stats.TotalEscaped
 	stats.TotalBlocked = b.stats.TotalBlocked
+	stats.LastUnblockBatchSize = b.stats.LastUnblockBatchSize
+	stats.TotalQuotaBlocked = b.stats.TotalQuotaBlocked
+	for reason, count := range b.stats.TotalBlockedByReason {
+		stats.TotalBlockedByReason[reason] = count
+	}
+	for quota, count := range b.stats.TotalQuotaBlockedByQuota {
+		stats.TotalQuotaBlockedByQuota[quota] = count
+	}
+
+	b.pendingMu.Lock()
+	stats.TotalRawUnblockEvents = b.rawUnblockEvents
+	stats.TotalCoalescedUnblockEvents = b.coalescedUnblockEvents
+	b.pendingMu.Unlock()
+
 	return stats
 }
 
@@ -296,6 +943,16 @@ func (b *BlockedEvals) EmitStats(period time.Duration, stopCh chan struct{}) {
 			stats := b.Stats()
 			metrics.SetGauge([]string{"nomad", "blocked_evals", "total_blocked"}, float32(stats.TotalBlocked))
 			metrics.SetGauge([]string{"nomad", "blocked_evals", "total_escaped"}, float32(stats.TotalEscaped))
+			for reason, count := range stats.TotalBlockedByReason {
+				metrics.SetGauge([]string{"nomad", "blocked_evals", string(reason)}, float32(count))
+			}
+			metrics.SetGauge([]string{"nomad", "blocked_evals", "total_raw_unblock_events"}, float32(stats.TotalRawUnblockEvents))
+			metrics.SetGauge([]string{"nomad", "blocked_evals", "total_coalesced_unblock_events"}, float32(stats.TotalCoalescedUnblockEvents))
+			metrics.SetGauge([]string{"nomad", "blocked_evals", "last_unblock_batch_size"}, float32(stats.LastUnblockBatchSize))
+			metrics.SetGauge([]string{"nomad", "blocked_evals", "total_quota_blocked"}, float32(stats.TotalQuotaBlocked))
+			for quota, count := range stats.TotalQuotaBlockedByQuota {
+				metrics.SetGauge([]string{"nomad", "blocked_evals", "quota", quota}, float32(count))
+			}
 		case <-stopCh:
 			return
 		}
@@ -0,0 +1,251 @@
+package nomad
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/nomad/mock"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/hashicorp/nomad/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeCapacity_Satisfies_Magnitude(t *testing.T) {
+	capacity := &NodeCapacity{Memory: 2048}
+
+	// required of 0 means the magnitude isn't known; presence is enough.
+	require.True(t, capacity.satisfies(BlockedReasonMemory, 0))
+
+	// A delta that covers the requirement satisfies it.
+	require.True(t, capacity.satisfies(BlockedReasonMemory, 2048))
+
+	// A delta smaller than the recorded requirement must not satisfy it.
+	require.False(t, capacity.satisfies(BlockedReasonMemory, 8192))
+}
+
+func TestBlockedReasonsForEval_Magnitude(t *testing.T) {
+	eval := mock.Eval()
+	eval.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {
+			DimensionExhausted: map[string]int{"memory": 1},
+			ResourcesExhausted: map[string]*structs.Resources{
+				"web": {MemoryMB: 8192},
+			},
+		},
+	}
+
+	reasons := blockedReasonsForEval(eval)
+	required, ok := reasons[BlockedReasonMemory]
+	require.True(t, ok)
+	require.Equal(t, 8192, required)
+}
+
+// TestBlockedEvals_Unblock_NeverSeenClass ensures an eval that never saw the
+// notified computed class is unblocked unconditionally, even when the
+// capacity delta couldn't satisfy its recorded blocked reason. Gating on
+// the reason heuristic in that case would strand the eval indefinitely.
+func TestBlockedEvals_Unblock_NeverSeenClass(t *testing.T) {
+	b := NewBlockedEvals(testBroker(t, 0))
+	b.SetEnabled(true)
+	defer b.Flush()
+
+	eval := mock.Eval()
+	eval.ClassEligibility = map[string]bool{"other-class": true}
+	eval.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {
+			DimensionExhausted: map[string]int{"memory": 1},
+			ResourcesExhausted: map[string]*structs.Resources{
+				"web": {MemoryMB: 8192},
+			},
+		},
+	}
+	b.Block(eval)
+	require.Equal(t, 1, b.Stats().TotalBlocked)
+
+	// "never-seen-class" never appears in eval.ClassEligibility, and the
+	// tiny delta couldn't satisfy the recorded 8192MB requirement anyway.
+	b.UnblockWithCapacity("never-seen-class", &NodeCapacity{Memory: 1})
+
+	testutil.WaitForResult(func() (bool, error) {
+		return b.Stats().TotalBlocked == 0, nil
+	}, func(err error) {
+		t.Fatalf("eval was never unblocked: %v", err)
+	})
+}
+
+// TestBlockedEvals_Unblock_SeenClass_InsufficientCapacity ensures an eval
+// that did see the notified class, and recorded a memory requirement, stays
+// blocked when the capacity delta is too small to plausibly satisfy it.
+func TestBlockedEvals_Unblock_SeenClass_InsufficientCapacity(t *testing.T) {
+	b := NewBlockedEvals(testBroker(t, 0))
+	b.SetEnabled(true)
+	defer b.Flush()
+
+	eval := mock.Eval()
+	eval.ClassEligibility = map[string]bool{"web-class": true}
+	eval.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {
+			DimensionExhausted: map[string]int{"memory": 1},
+			ResourcesExhausted: map[string]*structs.Resources{
+				"web": {MemoryMB: 8192},
+			},
+		},
+	}
+	b.Block(eval)
+
+	b.UnblockWithCapacity("web-class", &NodeCapacity{Memory: 2048})
+
+	time.Sleep(2 * defaultCoalesceWindow)
+	require.Equal(t, 1, b.Stats().TotalBlocked)
+}
+
+// TestBlockedEvals_SnapshotRestore_PreservesBuckets ensures a Snapshot
+// restored into a fresh tracker puts each eval back into the same
+// captured/escaped/quota bucket it was blocked in, with the stats
+// recomputed from the restored membership.
+func TestBlockedEvals_SnapshotRestore_PreservesBuckets(t *testing.T) {
+	captured := mock.Eval()
+	captured.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {DimensionExhausted: map[string]int{"memory": 1}},
+	}
+
+	escaped := mock.Eval()
+	escaped.EscapedComputedClass = true
+
+	quotaBlocked := mock.Eval()
+	quotaBlocked.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {QuotaExhausted: []string{"default"}},
+	}
+
+	src := NewBlockedEvals(testBroker(t, 0))
+	src.SetEnabled(true)
+	defer src.Flush()
+	src.Block(captured)
+	src.Block(escaped)
+	src.Block(quotaBlocked)
+
+	dst := NewBlockedEvals(testBroker(t, 0))
+	dst.SetEnabled(true)
+	defer dst.Flush()
+	dst.Restore(src.Snapshot())
+
+	stats := dst.Stats()
+	require.Equal(t, 3, stats.TotalBlocked)
+	require.Equal(t, 1, stats.TotalQuotaBlocked)
+
+	dst.l.RLock()
+	_, isCaptured := dst.captured[captured.ID]
+	_, isEscaped := dst.escaped[escaped.ID]
+	_, isQuota := dst.quotaBlocked[quotaBlocked.ID]
+	dst.l.RUnlock()
+
+	require.True(t, isCaptured)
+	require.True(t, isEscaped)
+	require.True(t, isQuota)
+}
+
+// TestBlockedEvals_FlushPending_BackpressureNoDuplicateTimer drives the
+// flushPending backpressure branch and asserts it merges the batch back and
+// arms a pendingTimer, and that an Unblock arriving afterwards joins the
+// pending set under that same timer rather than starting a second one. Two
+// live timers would both eventually fire and flush the same coalesced batch
+// twice.
+func TestBlockedEvals_FlushPending_BackpressureNoDuplicateTimer(t *testing.T) {
+	b := NewBlockedEvals(testBroker(t, 0))
+	b.unblockCh = make(chan *unblockUpdate) // unbuffered and never received from: every send hits the default case
+	b.enabled = true                        // enable without SetEnabled, which would start unblock() receiving on unblockCh
+	defer b.Flush()
+
+	b.pendingMu.Lock()
+	b.pending["class-a"] = &NodeCapacity{CPU: 100}
+	b.pendingMu.Unlock()
+
+	// With no receiver, the send on unblockCh hits the default case and
+	// flushPending takes the backpressure branch.
+	done := make(chan struct{})
+	go func() {
+		b.flushPending()
+		close(done)
+	}()
+	<-done
+
+	b.pendingMu.Lock()
+	armed := b.pendingTimer
+	b.pendingMu.Unlock()
+	require.NotNil(t, armed)
+
+	// Unblock adds a new class. It must see the armed timer and not start
+	// its own.
+	b.Unblock("class-b")
+
+	b.pendingMu.Lock()
+	timer := b.pendingTimer
+	_, hasA := b.pending["class-a"]
+	_, hasB := b.pending["class-b"]
+	b.pendingMu.Unlock()
+
+	require.NotNil(t, timer)
+	require.True(t, timer == armed)
+	require.True(t, hasA)
+	require.True(t, hasB)
+}
+
+// TestBlockedEvals_QuotaBlocked_IsolatedFromNodeClassChanges ensures an eval
+// blocked on a namespace/quota limit is not woken by an unrelated node
+// class Unblock, and is only woken by UnblockQuota for its own quota.
+func TestBlockedEvals_QuotaBlocked_IsolatedFromNodeClassChanges(t *testing.T) {
+	b := NewBlockedEvals(testBroker(t, 0))
+	b.SetEnabled(true)
+	defer b.Flush()
+
+	eval := mock.Eval()
+	eval.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {QuotaExhausted: []string{"default"}},
+	}
+	b.Block(eval)
+
+	stats := b.Stats()
+	require.Equal(t, 1, stats.TotalBlocked)
+	require.Equal(t, 1, stats.TotalQuotaBlocked)
+	require.Equal(t, 1, stats.TotalQuotaBlockedByQuota["default"])
+
+	// An unrelated node class gaining capacity must not wake a quota-blocked
+	// eval.
+	b.UnblockWithCapacity("some-class", &NodeCapacity{CPU: 100, Memory: 100})
+	time.Sleep(2 * defaultCoalesceWindow)
+	require.Equal(t, 1, b.Stats().TotalQuotaBlocked)
+
+	// Only usage dropping under the exhausted quota wakes it.
+	b.UnblockQuota("default")
+
+	stats = b.Stats()
+	require.Equal(t, 0, stats.TotalBlocked)
+	require.Equal(t, 0, stats.TotalQuotaBlocked)
+	require.Equal(t, 0, stats.TotalQuotaBlockedByQuota["default"])
+}
+
+// TestBlockedEvals_QuotaBlocked_MultipleEvalsSameQuota ensures multiple
+// distinct evaluations blocked on the same quota are all tracked and all
+// woken together, rather than one overwriting another - a risk of keying
+// the quota bucket by quota name instead of by eval ID.
+func TestBlockedEvals_QuotaBlocked_MultipleEvalsSameQuota(t *testing.T) {
+	b := NewBlockedEvals(testBroker(t, 0))
+	b.SetEnabled(true)
+	defer b.Flush()
+
+	evalA := mock.Eval()
+	evalA.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {QuotaExhausted: []string{"default"}},
+	}
+	evalB := mock.Eval()
+	evalB.FailedTGAllocs = map[string]*structs.AllocMetric{
+		"web": {QuotaExhausted: []string{"default"}},
+	}
+
+	b.Block(evalA)
+	b.Block(evalB)
+	require.Equal(t, 2, b.Stats().TotalQuotaBlocked)
+
+	b.UnblockQuota("default")
+	require.Equal(t, 0, b.Stats().TotalQuotaBlocked)
+}